@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ConsulRegistry polls Consul's health-checked catalog endpoint for a named
+// service and emits the passing instances as the backend set.
+type ConsulRegistry struct {
+	Endpoint string
+	Service  string
+	Refresh  time.Duration
+
+	client *http.Client
+}
+
+type consulServiceEntry struct {
+	Service struct {
+		Address string
+		Port    int
+		Weights struct {
+			Passing int
+		}
+	}
+}
+
+func NewConsulRegistry(endpoint, service string, refresh time.Duration) *ConsulRegistry {
+	if refresh <= 0 {
+		refresh = 10 * time.Second
+	}
+	return &ConsulRegistry{
+		Endpoint: endpoint,
+		Service:  service,
+		Refresh:  refresh,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *ConsulRegistry) Watch(ctx context.Context) <-chan []BackendConfig {
+	out := make(chan []BackendConfig)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(c.Refresh)
+		defer ticker.Stop()
+		for {
+			backends, err := c.poll(ctx)
+			if err != nil {
+				log.Printf("consul registry: poll failed: %v", err)
+			} else {
+				select {
+				case out <- backends:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (c *ConsulRegistry) poll(ctx context.Context) ([]BackendConfig, error) {
+	url := fmt.Sprintf("%s/v1/health/service/%s?passing=true", c.Endpoint, c.Service)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	backends := make([]BackendConfig, 0, len(entries))
+	for _, e := range entries {
+		weight := e.Service.Weights.Passing
+		if weight <= 0 {
+			weight = 1
+		}
+		backends = append(backends, BackendConfig{
+			URL:    fmt.Sprintf("http://%s:%d", e.Service.Address, e.Service.Port),
+			Weight: weight,
+		})
+	}
+	return backends, nil
+}
+
+func (c *ConsulRegistry) Deregister() error {
+	return nil
+}
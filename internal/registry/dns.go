@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// DNSRegistry resolves a DNS SRV record on a timer, turning each returned
+// target/port pair into a backend. Used when backends are registered via a
+// headless Kubernetes service or similar SRV-publishing DNS.
+type DNSRegistry struct {
+	Service  string
+	Proto    string
+	Name     string
+	Refresh  time.Duration
+	resolver *net.Resolver
+}
+
+func NewDNSRegistry(service, proto, name string, refresh time.Duration) *DNSRegistry {
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+	return &DNSRegistry{
+		Service:  service,
+		Proto:    proto,
+		Name:     name,
+		Refresh:  refresh,
+		resolver: net.DefaultResolver,
+	}
+}
+
+func (d *DNSRegistry) Watch(ctx context.Context) <-chan []BackendConfig {
+	out := make(chan []BackendConfig)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(d.Refresh)
+		defer ticker.Stop()
+		for {
+			backends, err := d.lookup(ctx)
+			if err != nil {
+				log.Printf("dns registry: SRV lookup failed: %v", err)
+			} else {
+				select {
+				case out <- backends:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (d *DNSRegistry) lookup(ctx context.Context) ([]BackendConfig, error) {
+	_, srvs, err := d.resolver.LookupSRV(ctx, d.Service, d.Proto, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	backends := make([]BackendConfig, 0, len(srvs))
+	for _, srv := range srvs {
+		weight := int(srv.Weight)
+		if weight <= 0 {
+			weight = 1
+		}
+		target := srv.Target
+		if len(target) > 0 && target[len(target)-1] == '.' {
+			target = target[:len(target)-1]
+		}
+		backends = append(backends, BackendConfig{
+			URL:    fmt.Sprintf("http://%s:%d", target, srv.Port),
+			Weight: weight,
+		})
+	}
+	return backends, nil
+}
+
+func (d *DNSRegistry) Deregister() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// EtcdRegistry watches a key prefix in etcd for backend entries, where each
+// key's value is a JSON-encoded BackendConfig.
+type EtcdRegistry struct {
+	Endpoints []string
+	Prefix    string
+
+	client *clientv3.Client
+	known  map[string]BackendConfig
+}
+
+func NewEtcdRegistry(endpoints []string, prefix string) (*EtcdRegistry, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &EtcdRegistry{
+		Endpoints: endpoints,
+		Prefix:    prefix,
+		client:    client,
+		known:     make(map[string]BackendConfig),
+	}, nil
+}
+
+func (e *EtcdRegistry) Watch(ctx context.Context) <-chan []BackendConfig {
+	out := make(chan []BackendConfig)
+	go func() {
+		defer close(out)
+
+		initial, err := e.client.Get(ctx, e.Prefix, clientv3.WithPrefix())
+		if err != nil {
+			log.Printf("etcd registry: initial get failed: %v", err)
+		} else {
+			for _, kv := range initial.Kvs {
+				var bc BackendConfig
+				if err := json.Unmarshal(kv.Value, &bc); err == nil {
+					e.known[string(kv.Key)] = bc
+				}
+			}
+			select {
+			case out <- e.snapshot():
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		watchCh := e.client.Watch(ctx, e.Prefix, clientv3.WithPrefix())
+		for resp := range watchCh {
+			for _, ev := range resp.Events {
+				key := string(ev.Kv.Key)
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					var bc BackendConfig
+					if err := json.Unmarshal(ev.Kv.Value, &bc); err == nil {
+						e.known[key] = bc
+					}
+				case clientv3.EventTypeDelete:
+					delete(e.known, key)
+				}
+			}
+			select {
+			case out <- e.snapshot():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (e *EtcdRegistry) snapshot() []BackendConfig {
+	backends := make([]BackendConfig, 0, len(e.known))
+	for _, bc := range e.known {
+		backends = append(backends, bc)
+	}
+	return backends
+}
+
+func (e *EtcdRegistry) Deregister() error {
+	return e.client.Close()
+}
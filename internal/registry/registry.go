@@ -0,0 +1,30 @@
+// Package registry abstracts over external service-discovery sources so the
+// load balancer can track a dynamically-scaled backend pool instead of only
+// a static YAML list.
+package registry
+
+import "context"
+
+// BackendConfig is the discovery-side view of an upstream backend, separate
+// from the balancer's own config.BackendConfig so this package doesn't need
+// to import the main binary's types.
+type BackendConfig struct {
+	URL    string
+	Weight int
+}
+
+// Registry watches an external source of truth for the current backend set
+// and emits the full set on every change (polling implementations emit it on
+// every poll, changed or not). Implementations are expected to keep watching
+// until ctx is cancelled, then close their channel.
+type Registry interface {
+	// Watch returns a channel that receives the complete current backend
+	// set each time it changes; the Consul and DNS registries send it on
+	// every poll. The channel is closed when ctx is done.
+	Watch(ctx context.Context) <-chan []BackendConfig
+
+	// Deregister releases whatever the registry holds: the etcd registry
+	// closes its client, while the Consul and DNS registries hold nothing
+	// and return nil.
+	Deregister() error
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// addBackend appends a new backend to the pool, guarded by the same mu used
+// for every other write to the servers slice.
+func (lb *LoadBalancer) addBackend(bc BackendConfig) {
+	srv := initialiseServer(bc)
+	lb.mu.Lock()
+	lb.servers = append(lb.servers, srv)
+	lb.mu.Unlock()
+	lb.rebuildHashRing()
+}
+
+// removeBackend drops the backend matching addr and reports whether one was
+// found.
+func (lb *LoadBalancer) removeBackend(addr string) bool {
+	lb.mu.Lock()
+	removed := false
+	for i, srv := range lb.servers {
+		if srv.Address() == addr {
+			lb.servers = append(lb.servers[:i], lb.servers[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	lb.mu.Unlock()
+	if removed {
+		lb.rebuildHashRing()
+	}
+	return removed
+}
+
+// setBackendDraining flips the draining flag on the backend matching addr so
+// it stops receiving new requests while in-flight ones finish.
+func (lb *LoadBalancer) setBackendDraining(addr string, draining bool) bool {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	for _, srv := range lb.servers {
+		if srv.Address() == addr {
+			srv.(*simpleServer).setDraining(draining)
+			return true
+		}
+	}
+	return false
+}
+
+func (lb *LoadBalancer) setAlgorithm(name string) {
+	lb.mu.Lock()
+	lb.algorithm = name
+	lb.mu.Unlock()
+}
+
+// reloadBackends re-reads configPath and reconciles the servers slice: new
+// backends are initialised, removed ones are dropped, and backends present
+// in both keep their existing health/connection state.
+func (lb *LoadBalancer) reloadBackends() error {
+	cfg, err := tryLoadConfig(lb.configPath)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*simpleServer)
+	lb.mu.RLock()
+	for _, srv := range lb.servers {
+		existing[srv.Address()] = srv.(*simpleServer)
+	}
+	lb.mu.RUnlock()
+
+	var next []Server
+	for _, bc := range cfg.Backends {
+		if srv, ok := existing[bc.URL]; ok {
+			next = append(next, srv)
+		} else {
+			next = append(next, initialiseServer(bc))
+		}
+	}
+
+	lb.mu.Lock()
+	lb.servers = next
+	if cfg.Algorithm != "" {
+		lb.algorithm = cfg.Algorithm
+	}
+	lb.mu.Unlock()
+	lb.rebuildHashRing()
+	return nil
+}
+
+func adminAuth(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if token != "" && req.Header.Get("X-Admin-Token") != token {
+			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(rw, req)
+	}
+}
+
+// startAdminServer runs the control-plane API on its own port, separate from
+// the data-plane listener, so it can be firewalled off independently.
+func startAdminServer(lb *LoadBalancer, cfg struct {
+	Port  string `yaml:"port"`
+	Token string `yaml:"token"`
+}) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/backends", adminAuth(cfg.Token, func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			URL    string `json:"url"`
+			Weight int    `json:"weight"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.URL == "" {
+			http.Error(rw, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		lb.addBackend(BackendConfig{URL: body.URL, Weight: body.Weight})
+		logAdminAction("add_backend", body.URL, "")
+		rw.WriteHeader(http.StatusCreated)
+	}))
+
+	mux.HandleFunc("/api/backends/", adminAuth(cfg.Token, func(rw http.ResponseWriter, req *http.Request) {
+		rest := strings.TrimPrefix(req.URL.Path, "/api/backends/")
+
+		if strings.HasSuffix(rest, "/drain") {
+			if req.Method != http.MethodPut {
+				http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			addr, err := url.PathUnescape(strings.TrimSuffix(rest, "/drain"))
+			if err != nil || addr == "" {
+				http.Error(rw, "Invalid backend URL", http.StatusBadRequest)
+				return
+			}
+			if !lb.setBackendDraining(addr, true) {
+				http.Error(rw, "Backend not found", http.StatusNotFound)
+				return
+			}
+			logAdminAction("drain_backend", addr, "")
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if req.Method != http.MethodDelete {
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		addr, err := url.PathUnescape(rest)
+		if err != nil || addr == "" {
+			http.Error(rw, "Invalid backend URL", http.StatusBadRequest)
+			return
+		}
+		if !lb.removeBackend(addr) {
+			http.Error(rw, "Backend not found", http.StatusNotFound)
+			return
+		}
+		logAdminAction("remove_backend", addr, "")
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	mux.HandleFunc("/api/algorithm", adminAuth(cfg.Token, func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut {
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Name == "" {
+			http.Error(rw, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		lb.setAlgorithm(body.Name)
+		logAdminAction("set_algorithm", body.Name, "")
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	mux.HandleFunc("/api/reload", adminAuth(cfg.Token, func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := lb.reloadBackends(); err != nil {
+			http.Error(rw, "Reload failed", http.StatusInternalServerError)
+			return
+		}
+		logAdminAction("reload", lb.configPath, "")
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	log.Println("Admin API starting on :" + cfg.Port)
+	if err := http.ListenAndServe(":"+cfg.Port, mux); err != nil {
+		log.Printf("Admin server failed: %v", err)
+	}
+}
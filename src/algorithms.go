@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// getWeightedRoundRobinServer implements smooth weighted round-robin
+// (the same scheme nginx uses): every pick bumps each live backend's
+// currentWeight by its configured weight, hands the request to whichever
+// backend now has the highest currentWeight, then knocks the total weight
+// off the winner. That spreads picks proportionally without bursting all of
+// a heavy backend's requests back-to-back.
+func (lb *LoadBalancer) getWeightedRoundRobinServer() Server {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	var selected *simpleServer
+	total := 0
+	for _, srv := range lb.servers {
+		ss := srv.(*simpleServer)
+		if !ss.available() {
+			continue
+		}
+		ss.currentWeight += ss.weight
+		total += ss.weight
+		if selected == nil || ss.currentWeight > selected.currentWeight {
+			selected = ss
+		}
+	}
+	if selected == nil {
+		return nil
+	}
+	selected.currentWeight -= total
+	return selected
+}
+
+// hashRing is a consistent-hash ring with a fixed number of virtual nodes
+// per backend, so adding or removing one backend only reshuffles roughly
+// that backend's share of the keyspace (1/number of backends), spread evenly
+// by its virtual nodes.
+const hashRingVirtualNodes = 150
+
+type hashRingNode struct {
+	hash   uint64
+	server *simpleServer
+}
+
+type hashRing struct {
+	nodes []hashRingNode
+}
+
+// buildHashRing places every backend on the ring regardless of current
+// health/draining state: the ring reflects membership, not availability, so
+// it only needs rebuilding when a backend is added or removed. Unavailable
+// backends are skipped at lookup time instead (see hashRing.get), the same
+// way the other selectors walk past unhealthy candidates.
+func buildHashRing(servers []Server) *hashRing {
+	ring := &hashRing{}
+	for _, srv := range servers {
+		ss := srv.(*simpleServer)
+		for i := 0; i < hashRingVirtualNodes; i++ {
+			key := ss.addr + "#" + strconv.Itoa(i)
+			ring.nodes = append(ring.nodes, hashRingNode{
+				hash:   xxhash.Sum64String(key),
+				server: ss,
+			})
+		}
+	}
+	sort.Slice(ring.nodes, func(i, j int) bool { return ring.nodes[i].hash < ring.nodes[j].hash })
+	return ring
+}
+
+// get finds the first node whose hash is >= key's hash, wrapping around to
+// the start of the ring, then walks forward from there until it finds a
+// backend that's currently available. Returns nil if none are.
+func (r *hashRing) get(key string) *simpleServer {
+	if len(r.nodes) == 0 {
+		return nil
+	}
+	h := xxhash.Sum64String(key)
+	start := sort.Search(len(r.nodes), func(i int) bool { return r.nodes[i].hash >= h })
+	for i := 0; i < len(r.nodes); i++ {
+		idx := (start + i) % len(r.nodes)
+		if r.nodes[idx].server.available() {
+			return r.nodes[idx].server
+		}
+	}
+	return nil
+}
+
+// hashKey extracts the configurable sticky-session key for consistent
+// hashing: the client IP, a header, or a cookie, falling back to the
+// client's remote address when the configured source isn't present.
+func (lb *LoadBalancer) hashKey(req *http.Request) string {
+	switch lb.hashKeySource {
+	case "header":
+		if v := req.Header.Get(lb.hashHeaderName); v != "" {
+			return v
+		}
+	case "cookie":
+		if c, err := req.Cookie(lb.hashCookieName); err == nil {
+			return c.Value
+		}
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// rebuildHashRing recomputes the cached consistent-hash ring from the
+// current server set. Called whenever backend membership changes (add,
+// remove, discovery reconcile, reload) — never on the per-request path.
+func (lb *LoadBalancer) rebuildHashRing() {
+	lb.mu.RLock()
+	servers := append([]Server(nil), lb.servers...)
+	lb.mu.RUnlock()
+
+	ring := buildHashRing(servers)
+
+	lb.ringMu.Lock()
+	lb.hashRing = ring
+	lb.ringMu.Unlock()
+}
+
+func (lb *LoadBalancer) getConsistentHashServer(req *http.Request) Server {
+	lb.ringMu.RLock()
+	ring := lb.hashRing
+	lb.ringMu.RUnlock()
+	if ring == nil {
+		return nil
+	}
+
+	selected := ring.get(lb.hashKey(req))
+	if selected == nil {
+		return nil
+	}
+	return selected
+}
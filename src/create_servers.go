@@ -36,11 +36,11 @@ func create_servers() {
 	// ports := []int{9000, 9001, 9002}
 	cfg := loadConfig("../configs/config.yaml")
 	ports := cfg.Backends;
-	var strports []int 
-	for _, port := range ports {
+	var strports []int
+	for _, backend := range ports {
 		// println(port);
 		// err := nil
-		strport, err := extractPort(port)
+		strport, err := extractPort(backend.URL)
 		if(err != nil){
 			println("something went wrong")
 			return ;
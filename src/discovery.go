@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Aashi-001/Load-Balancer/loadbalancer/internal/registry"
+)
+
+// departedBackendGrace is how long a departed backend stays drained before
+// it's dropped from the pool entirely, giving in-flight requests time to
+// finish without routing new ones to it.
+const departedBackendGrace = 30 * time.Second
+
+// newRegistry builds the configured discovery backend, or nil when no
+// discovery type is set (in which case the static YAML backend list is
+// authoritative, as before).
+func newRegistry(cfg Config) (registry.Registry, error) {
+	switch cfg.Discovery.Type {
+	case "":
+		return nil, nil
+	case "consul":
+		if len(cfg.Discovery.Endpoints) == 0 {
+			return nil, fmt.Errorf("discovery: consul requires at least one endpoint")
+		}
+		return registry.NewConsulRegistry(cfg.Discovery.Endpoints[0], cfg.Discovery.Service, time.Duration(cfg.Discovery.Refresh)), nil
+	case "etcd":
+		return registry.NewEtcdRegistry(cfg.Discovery.Endpoints, cfg.Discovery.Service)
+	case "dns":
+		return registry.NewDNSRegistry("http", "tcp", cfg.Discovery.Service, time.Duration(cfg.Discovery.Refresh)), nil
+	default:
+		return nil, fmt.Errorf("discovery: unknown type %q", cfg.Discovery.Type)
+	}
+}
+
+// watchDiscovery consumes the registry's watch channel for the lifetime of
+// ctx, reconciling the balancer's backend set on every update.
+func watchDiscovery(ctx context.Context, lb *LoadBalancer, reg registry.Registry) {
+	updates := reg.Watch(ctx)
+	for {
+		select {
+		case backends, ok := <-updates:
+			if !ok {
+				return
+			}
+			lb.reconcileDiscovered(backends)
+		case <-ctx.Done():
+			reg.Deregister()
+			return
+		}
+	}
+}
+
+// reconcileDiscovered diffs the current server set against the latest
+// discovery snapshot: new addresses are added via initialiseServer, and
+// addresses no longer present are drained and removed after a grace period.
+func (lb *LoadBalancer) reconcileDiscovered(backends []registry.BackendConfig) {
+	want := make(map[string]registry.BackendConfig, len(backends))
+	for _, bc := range backends {
+		want[bc.URL] = bc
+	}
+
+	wantAddrs := make(map[string]struct{}, len(want))
+	for addr := range want {
+		wantAddrs[addr] = struct{}{}
+	}
+	lb.discoveredMu.Lock()
+	lb.discoveredAddrs = wantAddrs
+	lb.discoveredMu.Unlock()
+
+	lb.mu.RLock()
+	present := make(map[string]bool, len(lb.servers))
+	for _, srv := range lb.servers {
+		present[srv.Address()] = true
+	}
+	lb.mu.RUnlock()
+
+	for addr, bc := range want {
+		if !present[addr] {
+			lb.addBackend(BackendConfig{URL: bc.URL, Weight: bc.Weight})
+			log.Printf("discovery: added backend %s", addr)
+		} else {
+			// Still wanted: undrain immediately in case it was mid-grace-period
+			// from a prior reconcile (a flap or quick scale-down/up), rather
+			// than waiting on the pending removal goroutine to notice.
+			lb.setBackendDraining(addr, false)
+		}
+	}
+
+	for addr := range present {
+		if _, ok := want[addr]; !ok {
+			lb.setBackendDraining(addr, true)
+			log.Printf("discovery: draining departed backend %s", addr)
+			go func(addr string) {
+				time.Sleep(departedBackendGrace)
+
+				lb.discoveredMu.RLock()
+				_, stillWanted := lb.discoveredAddrs[addr]
+				lb.discoveredMu.RUnlock()
+				if stillWanted {
+					lb.setBackendDraining(addr, false)
+					log.Printf("discovery: %s reappeared during grace period, cancelling removal", addr)
+					return
+				}
+
+				lb.removeBackend(addr)
+				log.Printf("discovery: removed departed backend %s", addr)
+			}(addr)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -9,14 +11,17 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 	"gopkg.in/yaml.v2"
 	"database/sql"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/prometheus/client_golang/prometheus"
     "github.com/prometheus/client_golang/prometheus/promhttp"
+    "golang.org/x/net/http2"
 )
 
 // import "os"
@@ -57,33 +62,162 @@ var (
     )
 )
 
+// Duration wraps time.Duration so a YAML config field can be given either as
+// a duration string like "30s" or as an integer nanosecond count. Only
+// LameDuck and Discovery.Refresh use it; the other duration fields below are
+// plain time.Duration.
+type Duration time.Duration
+
+// UnmarshalYAML accepts either a duration string ("30s", "1m") or a plain
+// integer nanosecond count.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var n int64
+	if err := unmarshal(&n); err != nil {
+		return err
+	}
+	*d = Duration(n)
+	return nil
+}
+
 type Config struct {
 	Server struct {
-		Host string `yaml:"host"`
-		Port string    `yaml:"port"`
+		Host        string        `yaml:"host"`
+		Port        string        `yaml:"port"`
+		HTTPSPort   string        `yaml:"https_port"`
+		LameDuck    Duration      `yaml:"lame_duck"`
+		WebSocketIdleTimeout time.Duration `yaml:"websocket_idle_timeout"`
+		TLS         struct {
+			Cert string `yaml:"cert"`
+			Key  string `yaml:"key"`
+		} `yaml:"tls"`
 	} `yaml:"server"`
 
-	Backends    []string `yaml:"backends"`
+	Backends    []BackendConfig `yaml:"backends"`
 	Algorithm   string   `yaml:"algorithm"`
 	HealthCheck struct {
 		Interval time.Duration `yaml:"interval"`
 		Timeout  time.Duration `yaml:"timeout"`
 		Path     string        `yaml:"path"`
 	} `yaml:"health_check"`
+
+	Admin struct {
+		Port  string `yaml:"port"`
+		Token string `yaml:"token"`
+	} `yaml:"admin"`
+
+	Discovery struct {
+		Type      string        `yaml:"type"`
+		Endpoints []string      `yaml:"endpoints"`
+		Service   string        `yaml:"service"`
+		Refresh   Duration      `yaml:"refresh"`
+	} `yaml:"discovery"`
+
+	ConsistentHash struct {
+		// Source selects where the sticky-session key comes from: "ip"
+		// (client IP), "header" (HeaderName), or "cookie" (CookieName).
+		Source     string `yaml:"source"`
+		HeaderName string `yaml:"header_name"`
+		CookieName string `yaml:"cookie_name"`
+	} `yaml:"consistent_hash"`
+
+	RateLimit struct {
+		Enabled           bool    `yaml:"enabled"`
+		RequestsPerSecond float64 `yaml:"requests_per_second"`
+		Burst             int     `yaml:"burst"`
+		KeyHeader         string  `yaml:"key_header"`
+	} `yaml:"rate_limit"`
+
+	Retry struct {
+		Enabled     bool          `yaml:"enabled"`
+		MaxRetries  int           `yaml:"max_retries"`
+		BaseBackoff time.Duration `yaml:"base_backoff"`
+	} `yaml:"retry"`
+
+	CircuitBreaker struct {
+		Enabled      bool          `yaml:"enabled"`
+		FailureRatio float64       `yaml:"failure_ratio"`
+		Window       time.Duration `yaml:"window"`
+		Cooldown     time.Duration `yaml:"cooldown"`
+	} `yaml:"circuit_breaker"`
+}
+
+// BackendConfig describes one upstream backend. Weight is only consulted by
+// the weighted round-robin algorithm and defaults to 1 when omitted.
+type BackendConfig struct {
+	URL    string `yaml:"url"`
+	Weight int    `yaml:"weight"`
+}
+
+// UnmarshalYAML lets `backends:` keep accepting the old plain-string-list
+// form (`- http://host:port`) alongside the new `{url, weight}` mapping form,
+// so existing config.yaml files don't need to change.
+func (b *BackendConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var plain string
+	if err := unmarshal(&plain); err == nil {
+		b.URL = plain
+		b.Weight = 1
+		return nil
+	}
+
+	type plainBackend BackendConfig
+	var bc plainBackend
+	if err := unmarshal(&bc); err != nil {
+		return err
+	}
+	*b = BackendConfig(bc)
+	if b.Weight <= 0 {
+		b.Weight = 1
+	}
+	return nil
 }
 
 type simpleServer struct {
 	addr        string
+	url         *url.URL
 	proxy       *httputil.ReverseProxy
 	activeConns int32
+	weight      int
+	currentWeight int
 	alive       bool
+	draining    bool
 	mu          sync.RWMutex
 	requests    int64
+	breaker     *circuitBreaker
+}
+
+// available reports whether the backend may currently receive new requests:
+// it must be healthy, not in the process of draining for removal, and not
+// tripped by its circuit breaker. This is a pure read with no side effects —
+// it's called many times per request across the selectors, so it must not
+// itself advance the breaker's state machine. The backend actually chosen
+// for a request is admitted separately via breaker.admit.
+func (s *simpleServer) available() bool {
+	s.mu.RLock()
+	alive := s.alive && !s.draining
+	s.mu.RUnlock()
+	return alive && s.breaker.isAvailable()
+}
+
+func (s *simpleServer) setDraining(draining bool) {
+	s.mu.Lock()
+	s.draining = draining
+	s.mu.Unlock()
 }
 
 type Server interface {
 	Address() string
 	isAlive() bool
+	available() bool
 	Serve(rw http.ResponseWriter, r *http.Request)
 }
 
@@ -93,6 +227,72 @@ type LoadBalancer struct {
 	port      string
 	algorithm string
 	mu        sync.RWMutex
+	inflight  sync.WaitGroup
+	wsIdleTimeout time.Duration
+	configPath    string
+	hashKeySource string
+	hashHeaderName string
+	hashCookieName string
+
+	retryEnabled    bool
+	retryMax        int
+	retryBaseBackoff time.Duration
+
+	shuttingDown int32
+
+	ringMu   sync.RWMutex
+	hashRing *hashRing
+
+	discoveredMu    sync.RWMutex
+	discoveredAddrs map[string]struct{}
+}
+
+// enterLameDuck marks every backend unhealthy and stops the periodic health
+// check loop from undoing that, so lame-duck shutdown sticks until the
+// process actually exits.
+func (lb *LoadBalancer) enterLameDuck() {
+	atomic.StoreInt32(&lb.shuttingDown, 1)
+	lb.markAllUnhealthy()
+}
+
+func (lb *LoadBalancer) isShuttingDown() bool {
+	return atomic.LoadInt32(&lb.shuttingDown) == 1
+}
+
+// certReloader serves the most recently loaded TLS certificate from disk and
+// re-reads cert/key whenever reload() is called, so a SIGHUP can rotate certs
+// without dropping the listener.
+type certReloader struct {
+	certPath string
+	keyPath  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+}
+
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	cr := &certReloader{certPath: certPath, keyPath: keyPath}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certPath, cr.keyPath)
+	if err != nil {
+		return err
+	}
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.mu.Unlock()
+	log.Println("TLS certificate reloaded from", cr.certPath)
+	return nil
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
 }
 
 type loggingResponseWriter struct {
@@ -129,6 +329,13 @@ func setupDB(path string) *sql.DB {
         is_alive BOOLEAN,
         response_time_ms INTEGER
     );
+    CREATE TABLE IF NOT EXISTS admin_audit (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+        action TEXT,
+        target TEXT,
+        detail TEXT
+    );
     `
 
     _, err = db.Exec(schema)
@@ -155,6 +362,14 @@ func logHealthCheck(backend string, alive bool, respTime int64) {
     }
 }
 
+func logAdminAction(action, target, detail string) {
+    _, err := db.Exec(`INSERT INTO admin_audit (action, target, detail)
+                       VALUES (?, ?, ?)`, action, target, detail)
+    if err != nil {
+        log.Printf("DB insert failed: %v", err)
+    }
+}
+
 
 func handleErr(err error) {
 	if err != nil {
@@ -188,6 +403,13 @@ func (s *simpleServer) isAlive() bool {
 }
 
 func (s *simpleServer) checkHealth() {
+	s.mu.RLock()
+	draining := s.draining
+	s.mu.RUnlock()
+	if draining {
+		return
+	}
+
 	start := time.Now()
 	client := http.Client{Timeout: 2 * time.Second}
 	resp, err := client.Get(s.addr + "/health")
@@ -210,38 +432,65 @@ func (s *simpleServer) checkHealth() {
 	s.mu.Unlock()
 }
 
-func initialiseServer(addr string) *simpleServer {
+func initialiseServer(backend BackendConfig) *simpleServer {
+	addr := backend.URL
 	serverUrl, err := url.Parse(addr)
 	handleErr(err)
+	proxy := httputil.NewSingleHostReverseProxy(serverUrl)
+	if serverUrl.Scheme == "https" {
+		// Upgrade the upstream transport to negotiate h2 over ALPN so
+		// HTTPS backends aren't stuck on HTTP/1.1 through the proxy.
+		transport := &http.Transport{}
+		if err := http2.ConfigureTransport(transport); err != nil {
+			log.Printf("failed to configure HTTP/2 transport for %s: %v", addr, err)
+		} else {
+			proxy.Transport = transport
+		}
+	}
+	weight := backend.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	breaker := newCircuitBreaker()
+	breaker.backend = addr
 	return &simpleServer{
-		addr:  addr,
-		proxy: httputil.NewSingleHostReverseProxy(serverUrl),
-		alive: true,
+		addr:    addr,
+		url:     serverUrl,
+		proxy:   proxy,
+		weight:  weight,
+		alive:   true,
+		breaker: breaker,
 	}
 }
 
 func newLoadBalancer(servers []Server, port string, algo string) *LoadBalancer {
-	return &LoadBalancer{
+	lb := &LoadBalancer{
 		servers:   servers,
 		port:      port,
 		algorithm: algo,
 	}
+	lb.rebuildHashRing()
+	return lb
 }
 
 var rrCounter int32
 
 func (lb *LoadBalancer) getNextRoundRobinServer() Server {
-	total := len(lb.servers)
+	lb.mu.RLock()
+	servers := lb.servers
+	lb.mu.RUnlock()
+
+	total := len(servers)
     if total == 0 {
         return nil
     }
-    
+
     start := int(atomic.AddInt32(&rrCounter, 1) % int32(total))
-    
+
     for i := range total {
         index := (start + i) % total
-        if lb.servers[index].isAlive() {
-            return lb.servers[index]
+        if servers[index].available() {
+            return servers[index]
         }
     }
     return nil
@@ -283,7 +532,7 @@ func (lb *LoadBalancer) getLeastConnServer() Server {
 	lb.mu.RLock()
 	defer lb.mu.RUnlock()
 	for _, srv := range lb.servers {
-		if srv.isAlive() {
+		if srv.available() {
 			ss := srv.(*simpleServer)
 			if c := ss.getActiveConns(); c < min {
 				selected = ss
@@ -302,26 +551,65 @@ func (lb *LoadBalancer) getLeastConnServer() Server {
 }
 
 func (lb *LoadBalancer) getRandomServer() Server {
-	for {
-		server := lb.servers[rand.Intn(len(lb.servers))]
-		if server.isAlive() {
+	lb.mu.RLock()
+	servers := lb.servers
+	lb.mu.RUnlock()
+
+	n := len(servers)
+	if n == 0 {
+		return nil
+	}
+	start := rand.Intn(n)
+	for i := 0; i < n; i++ {
+		server := servers[(start+i)%n]
+		if server.available() {
 			return server
 		}
 	}
+	return nil
+}
+
+// markAllUnhealthy flips every backend to unhealthy and updates /metrics,
+// used when entering lame-duck shutdown so new traffic stops being routed
+// here before the process actually stops accepting connections.
+func (lb *LoadBalancer) markAllUnhealthy() {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	for _, srv := range lb.servers {
+		ss := srv.(*simpleServer)
+		ss.mu.Lock()
+		ss.alive = false
+		ss.mu.Unlock()
+		backendHealth.WithLabelValues(ss.addr).Set(0)
+	}
 }
 
-func (lb *LoadBalancer) getNextAvailableSever() Server {
-	println(lb.algorithm)
-	switch lb.algorithm {
+func (lb *LoadBalancer) getNextAvailableSever(req *http.Request) Server {
+	algorithm := lb.getAlgorithm()
+	println(algorithm)
+	switch algorithm {
 	case "roundrobin":
 		return lb.getNextRoundRobinServer()
 	case "leastconn":
 		return lb.getLeastConnServer()
+	case "weighted_roundrobin":
+		return lb.getWeightedRoundRobinServer()
+	case "consistent_hash":
+		return lb.getConsistentHashServer(req)
 	default:
 		return lb.getRandomServer()
 	}
 }
 
+// getAlgorithm reads the active algorithm name under RLock: setAlgorithm and
+// reloadBackends can reassign it from the admin API while requests are in
+// flight.
+func (lb *LoadBalancer) getAlgorithm() string {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.algorithm
+}
+
 func (s *simpleServer) Serve(rw http.ResponseWriter, req *http.Request) {
 	s.increment()
 	// defer s.decrement()
@@ -356,28 +644,84 @@ func init() {
     prometheus.MustRegister(backendHealth)
 }
 
+// backendHandler is the innermost stage of the proxy pipeline: it selects a
+// backend via the configured algorithm, admits it through its circuit
+// breaker, and serves the request. retryMiddleware calls it once per
+// attempt, each time free to land on a different backend.
+func (lb *LoadBalancer) backendHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		outcome := outcomeFrom(req)
+
+		targetServer := lb.getNextAvailableSever(req)
+		if targetServer == nil {
+			log.Println("No alive servers available!")
+			if outcome != nil {
+				outcome.statusCode = http.StatusServiceUnavailable
+			}
+			http.Error(rw, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Println("Serving request to ", targetServer.Address())
+
+		ss := targetServer.(*simpleServer)
+		if outcome != nil {
+			outcome.targetAddr = ss.addr
+		}
+
+		if isWebsocketUpgrade(req) {
+			lb.serveWebSocket(rw, req, ss)
+			if outcome != nil {
+				outcome.statusCode = 101
+			}
+			requestsTotal.WithLabelValues(ss.addr, lb.getAlgorithm(), "101").Inc()
+			return
+		}
+
+		serve := http.HandlerFunc(func(rw2 http.ResponseWriter, req2 *http.Request) {
+			lrw := &loggingResponseWriter{ResponseWriter: rw2, statusCode: http.StatusOK}
+			targetServer.Serve(lrw, req2)
+			ss.breaker.recordResult(lrw.statusCode < http.StatusInternalServerError)
+			if outcome != nil {
+				outcome.statusCode = lrw.statusCode
+			}
+		})
+		chain(serve, circuitBreakerMiddleware(ss.breaker)).ServeHTTP(rw, req)
+	})
+}
+
+// serveProxy is the data-plane entry point: rate limiting runs as the outer
+// Middleware (wired in main()), retry is composed here around
+// backendHandler, and backendHandler applies the chosen backend's circuit
+// breaker.
 func (lb *LoadBalancer) serveProxy(rw http.ResponseWriter, req *http.Request) {
+	lb.inflight.Add(1)
+	defer lb.inflight.Done()
+
 	start := time.Now()
 	log.Println("Incoming request")
-	targetServer := lb.getNextAvailableSever()
-	if targetServer == nil {
-		log.Println("No alive servers available!")
-		http.Error(rw, "Service unavailable", http.StatusServiceUnavailable)
-		return
-	}
-	fmt.Println("Serving request to ", targetServer.Address())
 
-	lrw := &loggingResponseWriter{ResponseWriter: rw, statusCode: http.StatusOK}
+	req, outcome := newOutcomeContext(req)
 
-	targetAddr := targetServer.Address()
+	chain(lb.backendHandler(), lb.retryMiddleware).ServeHTTP(rw, req)
 
-	targetServer.Serve(lrw, req)
+	if isWebsocketUpgrade(req) || outcome.targetAddr == "" {
+		return
+	}
 
 	duration := time.Since(start)
-    logRequest(req.RemoteAddr, req.URL.Path, targetAddr, duration.Milliseconds(), lrw.statusCode)
+	logRequest(req.RemoteAddr, req.URL.Path, outcome.targetAddr, duration.Milliseconds(), outcome.statusCode)
+
+	requestsTotal.WithLabelValues(outcome.targetAddr, lb.getAlgorithm(), fmt.Sprintf("%d", outcome.statusCode)).Inc()
+	responseTime.WithLabelValues(outcome.targetAddr, lb.getAlgorithm()).Observe(duration.Seconds())
+}
 
-	requestsTotal.WithLabelValues(targetAddr, lb.algorithm, fmt.Sprintf("%d", lrw.statusCode)).Inc()
-    responseTime.WithLabelValues(targetAddr, lb.algorithm).Observe(duration.Seconds())
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut:
+		return true
+	default:
+		return false
+	}
 }
 
 func readFile(path string) ([]byte, error) {
@@ -390,16 +734,26 @@ func readFile(path string) ([]byte, error) {
 }
 
 func loadConfig(path string) Config {
+	cfg, err := tryLoadConfig(path)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return cfg
+}
+
+// tryLoadConfig reads and parses path, returning an error instead of exiting
+// the process. Used by reloadBackends, where a bad config on disk must not
+// take down an already-running balancer the way a startup failure should.
+func tryLoadConfig(path string) (Config, error) {
 	data, err := readFile(path)
 	if err != nil {
-		log.Fatalf("Cannot read config file: %v", err)
+		return Config{}, fmt.Errorf("cannot read config file: %w", err)
 	}
 	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
-	if err != nil {
-		log.Fatalf("Invalid config YAML: %v", err)
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid config YAML: %w", err)
 	}
-	return cfg
+	return cfg, nil
 }
 
 func main() {
@@ -436,14 +790,52 @@ func main() {
 	// println("done")
 
 	var servers []Server
-	for _, addr := range cfg.Backends {
-		servers = append(servers, initialiseServer(addr))
+	for _, backend := range cfg.Backends {
+		servers = append(servers, initialiseServer(backend))
 	}
 
 	lb := newLoadBalancer(servers, cfg.Server.Port, cfg.Algorithm)
+	lb.wsIdleTimeout = cfg.Server.WebSocketIdleTimeout
+	lb.configPath = "../configs/config.yaml"
+	lb.hashKeySource = cfg.ConsistentHash.Source
+	lb.hashHeaderName = cfg.ConsistentHash.HeaderName
+	lb.hashCookieName = cfg.ConsistentHash.CookieName
+	lb.retryEnabled = cfg.Retry.Enabled
+	lb.retryMax = cfg.Retry.MaxRetries
+	lb.retryBaseBackoff = cfg.Retry.BaseBackoff
+
+	if cfg.RateLimit.Enabled {
+		rateLimiter = newRateLimiter(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst, cfg.RateLimit.KeyHeader)
+	}
+
+	globalCircuitBreakerConfig.enabled = cfg.CircuitBreaker.Enabled
+	if cfg.CircuitBreaker.FailureRatio > 0 {
+		globalCircuitBreakerConfig.failureRatio = cfg.CircuitBreaker.FailureRatio
+	}
+	if cfg.CircuitBreaker.Window > 0 {
+		globalCircuitBreakerConfig.window = cfg.CircuitBreaker.Window
+	}
+	if cfg.CircuitBreaker.Cooldown > 0 {
+		globalCircuitBreakerConfig.cooldown = cfg.CircuitBreaker.Cooldown
+	}
+
+	if cfg.Admin.Port != "" {
+		go startAdminServer(lb, cfg.Admin)
+	}
+
+	if reg, err := newRegistry(cfg); err != nil {
+		log.Printf("discovery: %v", err)
+	} else if reg != nil {
+		discoveryCtx, cancelDiscovery := context.WithCancel(context.Background())
+		defer cancelDiscovery()
+		go watchDiscovery(discoveryCtx, lb, reg)
+	}
 
 	go func() {
 		for {
+			if lb.isShuttingDown() {
+				return
+			}
 			for _, s := range lb.servers {
 				go s.(*simpleServer).checkHealth()
 			}
@@ -459,11 +851,81 @@ func main() {
         }
     }()
 
-	http.HandleFunc("/", lb.serveProxy)
+	mux := http.NewServeMux()
+	mux.Handle("/", chain(http.HandlerFunc(lb.serveProxy), rateLimitMiddleware))
+
+	httpServer := &http.Server{Addr: ":" + cfg.Server.Port, Handler: mux}
+
+	var httpsServer *http.Server
+	var reloader *certReloader
+	if cfg.Server.TLS.Cert != "" && cfg.Server.TLS.Key != "" {
+		var err error
+		reloader, err = newCertReloader(cfg.Server.TLS.Cert, cfg.Server.TLS.Key)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		httpsServer = &http.Server{
+			Addr:    ":" + cfg.Server.HTTPSPort,
+			Handler: mux,
+			TLSConfig: &tls.Config{
+				GetCertificate: reloader.GetCertificate,
+			},
+		}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		for sig := range sigCh {
+			switch sig {
+			case syscall.SIGHUP:
+				if reloader != nil {
+					if err := reloader.reload(); err != nil {
+						log.Printf("Failed to reload TLS certificate: %v", err)
+					}
+				}
+			case syscall.SIGTERM:
+				log.Println("Received SIGTERM, entering lame-duck shutdown")
+				lameDuck := time.Duration(cfg.Server.LameDuck)
+				if lameDuck <= 0 {
+					lameDuck = 10 * time.Second
+				}
+				lb.enterLameDuck()
+
+				ctx, cancel := context.WithTimeout(context.Background(), lameDuck)
+				httpServer.Shutdown(ctx)
+				if httpsServer != nil {
+					httpsServer.Shutdown(ctx)
+				}
+
+				drained := make(chan struct{})
+				go func() {
+					lb.inflight.Wait()
+					close(drained)
+				}()
+				select {
+				case <-drained:
+					log.Println("All in-flight requests finished")
+				case <-ctx.Done():
+					log.Println("Lame-duck period expired with requests still in flight")
+				}
+				cancel()
+				os.Exit(0)
+			}
+		}
+	}()
+
+	if httpsServer != nil {
+		go func() {
+			log.Printf("Starting HTTPS load balancer on port %s\n", cfg.Server.HTTPSPort)
+			if err := httpsServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Printf("HTTPS server failed: %v", err)
+			}
+		}()
+	}
 
 	log.Printf("Starting load balancer on port %s using %s algorithm\n", cfg.Server.Port, cfg.Algorithm)
-	err := http.ListenAndServe(":"+ cfg.Server.Port, nil)
-	if err != nil {
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
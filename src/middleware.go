@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ratelimitRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "lb_ratelimit_rejected_total",
+			Help: "Total number of requests rejected by the rate limiter",
+		},
+		[]string{"key"},
+	)
+
+	retriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "lb_retries_total",
+			Help: "Total number of upstream retries issued after a 5xx or connection error",
+		},
+		[]string{"backend"},
+	)
+
+	circuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "lb_circuit_state",
+			Help: "Circuit breaker state per backend (0=closed, 1=open, 2=half-open)",
+		},
+		[]string{"backend"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(ratelimitRejectedTotal)
+	prometheus.MustRegister(retriesTotal)
+	prometheus.MustRegister(circuitState)
+}
+
+// Middleware wraps an http.Handler with additional behaviour, composed with
+// chain() the same way gorilla's LoggingHandler wraps a handler.
+type Middleware func(http.Handler) http.Handler
+
+func chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] != nil {
+			h = mws[i](h)
+		}
+	}
+	return h
+}
+
+// outcomeCtxKey is the context key for the per-request attemptOutcome.
+type outcomeCtxKey struct{}
+
+// attemptOutcome carries result data out of the handlers in serveProxy's
+// middleware chain, since http.Handler has no return value: which backend
+// ended up serving the request and what status code it returned. Set by
+// backendHandler (and by circuitBreakerMiddleware on a refused admission),
+// read by retryMiddleware and by serveProxy once the chain returns.
+type attemptOutcome struct {
+	targetAddr string
+	statusCode int
+}
+
+// newOutcomeContext attaches a fresh attemptOutcome to req and returns both.
+func newOutcomeContext(req *http.Request) (*http.Request, *attemptOutcome) {
+	outcome := &attemptOutcome{}
+	return req.WithContext(context.WithValue(req.Context(), outcomeCtxKey{}, outcome)), outcome
+}
+
+func outcomeFrom(req *http.Request) *attemptOutcome {
+	outcome, _ := req.Context().Value(outcomeCtxKey{}).(*attemptOutcome)
+	return outcome
+}
+
+// circuitBreakerMiddleware gates next behind cb: a request the breaker won't
+// admit (Open, or Half-Open with a probe already in flight) fails fast with
+// 503 instead of reaching the backend. Unlike rateLimitMiddleware, cb is
+// bound per call since each backend has its own breaker.
+func circuitBreakerMiddleware(cb *circuitBreaker) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !cb.admit() {
+				if outcome := outcomeFrom(req); outcome != nil {
+					outcome.statusCode = http.StatusServiceUnavailable
+				}
+				http.Error(rw, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// retryMiddleware retries the wrapped handler on idempotent methods when the
+// upstream attempt returns a 5xx, capped at lb.retryMax with exponential
+// backoff and jitter. Each attempt runs against a fresh buffered response so
+// a retried attempt never partially writes to the real client connection;
+// only the winning attempt is flushed to rw. WebSocket upgrades bypass
+// buffering/retry entirely since the connection is hijacked, not written to.
+func (lb *LoadBalancer) retryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		maxRetries := 0
+		if lb.retryEnabled && isIdempotentMethod(req.Method) {
+			maxRetries = lb.retryMax
+		}
+
+		if maxRetries == 0 || isWebsocketUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		// A retried attempt needs the request body again, but the first
+		// attempt's reverse proxy drains req.Body as it streams upstream.
+		// Buffer it once up front and hand each attempt its own reader.
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				http.Error(rw, "Failed to read request body", http.StatusInternalServerError)
+				return
+			}
+			body = data
+		}
+
+		outcome := outcomeFrom(req)
+		for attempt := 0; ; attempt++ {
+			if body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			buffered := newBufferedResponseWriter()
+			next.ServeHTTP(buffered, req)
+
+			if buffered.statusCode < http.StatusInternalServerError || attempt >= maxRetries {
+				buffered.flushTo(rw)
+				return
+			}
+
+			if outcome != nil {
+				retriesTotal.WithLabelValues(outcome.targetAddr).Inc()
+			}
+			time.Sleep(retryBackoff(attempt, lb.retryBaseBackoff))
+		}
+	})
+}
+
+// tokenBucket is a classic token-bucket rate limiter: it refills at rate
+// tokens/sec up to burst, and a request is admitted only if a token is
+// available at request time.
+type tokenBucket struct {
+	mu         sync.Mutex
+	tokens     float64
+	ratePerSec float64
+	burst      float64
+	lastRefill time.Time
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	b.tokens += elapsed * b.ratePerSec
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter keys independent token buckets per client, either by a
+// configured request header or by client IP when no header is configured.
+type rateLimiterT struct {
+	ratePerSec float64
+	burst      int
+	keyHeader  string
+	mu         sync.Mutex
+	buckets    map[string]*tokenBucket
+}
+
+// rateLimiter is nil until the rate_limit block in config.yaml is enabled,
+// in which case main() populates it before the data-plane listener starts.
+var rateLimiter *rateLimiterT
+
+func newRateLimiter(ratePerSec float64, burst int, keyHeader string) *rateLimiterT {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &rateLimiterT{
+		ratePerSec: ratePerSec,
+		burst:      burst,
+		keyHeader:  keyHeader,
+		buckets:    make(map[string]*tokenBucket),
+	}
+}
+
+func (rl *rateLimiterT) keyFor(req *http.Request) string {
+	if rl.keyHeader != "" {
+		if v := req.Header.Get(rl.keyHeader); v != "" {
+			return v
+		}
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+func (rl *rateLimiterT) allow(req *http.Request) (string, bool) {
+	key := rl.keyFor(req)
+
+	rl.mu.Lock()
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(rl.burst), ratePerSec: rl.ratePerSec, burst: float64(rl.burst), lastRefill: time.Now()}
+		rl.buckets[key] = bucket
+	}
+	rl.mu.Unlock()
+
+	return key, bucket.allow()
+}
+
+// rateLimitMiddleware rejects requests with 429 once the caller's token
+// bucket is empty. It's a no-op until rate limiting is enabled in config.
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if rateLimiter == nil {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		key, ok := rateLimiter.allow(req)
+		if !ok {
+			ratelimitRejectedTotal.WithLabelValues(key).Inc()
+			http.Error(rw, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// retryBackoff returns an exponential backoff with full jitter for the given
+// retry attempt (0-indexed).
+func retryBackoff(attempt int, base time.Duration) time.Duration {
+	if base <= 0 {
+		base = 50 * time.Millisecond
+	}
+	max := base << uint(attempt)
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// circuitBreakerState mirrors the classic Closed -> Open -> Half-Open cycle.
+type circuitBreakerState int
+
+const (
+	circuitClosed circuitBreakerState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker trips a backend out of rotation once its failure ratio over
+// the configured window crosses the threshold, refuses traffic to it for
+// cooldown, then lets a single probe request through to decide whether to
+// close again.
+type circuitBreaker struct {
+	mu            sync.Mutex
+	state         circuitBreakerState
+	failures      int
+	total         int
+	windowStart   time.Time
+	openedAt      time.Time
+	backend       string
+	probeInFlight bool
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{state: circuitClosed, windowStart: time.Now()}
+}
+
+// isAvailable is a pure, side-effect-free read of whether the breaker would
+// currently admit a request: used by simpleServer.available() to filter
+// candidates during backend selection. It never mutates state or metrics —
+// call admit on the backend actually chosen to advance the state machine.
+func (cb *circuitBreaker) isAvailable() bool {
+	if circuitBreakerDisabled() {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		return time.Since(cb.openedAt) >= globalCircuitBreakerConfig.cooldown
+	case circuitHalfOpen:
+		return !cb.probeInFlight
+	default:
+		return true
+	}
+}
+
+// admit is called exactly once, on the backend chosen to serve a request. It
+// advances Open -> Half-Open once cooldown has elapsed and admits a single
+// probe while Half-Open; concurrent callers are refused until recordResult
+// clears the in-flight flag.
+func (cb *circuitBreaker) admit() bool {
+	if circuitBreakerDisabled() {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) >= globalCircuitBreakerConfig.cooldown {
+			cb.state = circuitHalfOpen
+			cb.probeInFlight = true
+			circuitState.WithLabelValues(cb.backend).Set(2)
+			return true
+		}
+		return false
+	case circuitHalfOpen:
+		if cb.probeInFlight {
+			return false
+		}
+		cb.probeInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *circuitBreaker) recordResult(success bool) {
+	if circuitBreakerDisabled() {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		cb.probeInFlight = false
+		if success {
+			cb.state = circuitClosed
+			cb.failures, cb.total = 0, 0
+			cb.windowStart = time.Now()
+			circuitState.WithLabelValues(cb.backend).Set(0)
+		} else {
+			cb.state = circuitOpen
+			cb.openedAt = time.Now()
+			circuitState.WithLabelValues(cb.backend).Set(1)
+		}
+		return
+	}
+
+	if time.Since(cb.windowStart) > globalCircuitBreakerConfig.window {
+		cb.failures, cb.total = 0, 0
+		cb.windowStart = time.Now()
+	}
+
+	cb.total++
+	if !success {
+		cb.failures++
+	}
+
+	if cb.total >= 1 && float64(cb.failures)/float64(cb.total) > globalCircuitBreakerConfig.failureRatio {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+		circuitState.WithLabelValues(cb.backend).Set(1)
+	}
+}
+
+// globalCircuitBreakerConfig holds the circuit_breaker block from
+// config.yaml; set once in main() before the data-plane listener starts.
+var globalCircuitBreakerConfig = struct {
+	enabled      bool
+	failureRatio float64
+	window       time.Duration
+	cooldown     time.Duration
+}{failureRatio: 0.5, window: 30 * time.Second, cooldown: 10 * time.Second}
+
+func circuitBreakerDisabled() bool {
+	return !globalCircuitBreakerConfig.enabled
+}
+
+// bufferedResponseWriter captures a handler's response so a retry attempt
+// can be discarded without partially writing to the real client connection.
+// retryMiddleware only allocates one when a retry is actually possible, but
+// when it does, the whole body is held in memory until the attempt finishes
+// — there's no streaming or size cap, so a large or long-lived response
+// (big download, SSE/chunked stream) on a retryable route is buffered in
+// full rather than flushed incrementally to the client.
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       []byte
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+}
+
+func (b *bufferedResponseWriter) Header() http.Header { return b.header }
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	b.body = append(b.body, p...)
+	return len(p), nil
+}
+
+func (b *bufferedResponseWriter) WriteHeader(code int) {
+	b.statusCode = code
+}
+
+func (b *bufferedResponseWriter) flushTo(rw http.ResponseWriter) {
+	for k, values := range b.header {
+		for _, v := range values {
+			rw.Header().Add(k, v)
+		}
+	}
+	rw.WriteHeader(b.statusCode)
+	rw.Write(b.body)
+}
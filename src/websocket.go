@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var activeWebsockets = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "lb_active_websockets",
+		Help: "Number of active WebSocket connections per backend",
+	},
+	[]string{"backend"},
+)
+
+func init() {
+	prometheus.MustRegister(activeWebsockets)
+}
+
+// isWebsocketUpgrade reports whether req is asking to upgrade the connection
+// to the WebSocket protocol, per RFC 6455.
+func isWebsocketUpgrade(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade")
+}
+
+func (s *simpleServer) incrementWS() {
+	activeWebsockets.WithLabelValues(s.addr).Inc()
+}
+
+func (s *simpleServer) decrementWS() {
+	activeWebsockets.WithLabelValues(s.addr).Dec()
+}
+
+// serveWebSocket hijacks the client connection, dials the backend directly
+// (httputil.ReverseProxy doesn't keep hijacked, long-lived connections alive
+// cleanly), replays the original request line and headers, then pipes both
+// directions until either side closes or the connection goes idle.
+func (lb *LoadBalancer) serveWebSocket(rw http.ResponseWriter, req *http.Request, target *simpleServer) {
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return
+	}
+
+	backendConn, err := net.Dial("tcp", target.url.Host)
+	if err != nil {
+		http.Error(rw, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		backendConn.Close()
+		log.Printf("Failed to hijack client connection: %v", err)
+		return
+	}
+
+	if err := req.Write(backendConn); err != nil {
+		log.Printf("Failed to relay upgrade request to %s: %v", target.addr, err)
+		clientConn.Close()
+		backendConn.Close()
+		return
+	}
+
+	// Hijack() may hand back bytes the client already sent past the request
+	// line (buffered in clientBuf.Reader); relay those before the raw-conn
+	// pipe takes over, or they'd be silently dropped.
+	if buffered := clientBuf.Reader.Buffered(); buffered > 0 {
+		pending := make([]byte, buffered)
+		if _, err := io.ReadFull(clientBuf.Reader, pending); err != nil {
+			log.Printf("Failed to drain buffered client data for %s: %v", target.addr, err)
+			clientConn.Close()
+			backendConn.Close()
+			return
+		}
+		if _, err := backendConn.Write(pending); err != nil {
+			log.Printf("Failed to relay buffered client data to %s: %v", target.addr, err)
+			clientConn.Close()
+			backendConn.Close()
+			return
+		}
+	}
+
+	target.incrementWS()
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			clientConn.Close()
+			backendConn.Close()
+			target.decrementWS()
+		})
+	}
+
+	idleTimeout := lb.wsIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = 60 * time.Second
+	}
+
+	pipe := func(dst net.Conn, src net.Conn) {
+		defer closeBoth()
+		buf := make([]byte, 32*1024)
+		for {
+			src.SetReadDeadline(time.Now().Add(idleTimeout))
+			n, err := src.Read(buf)
+			if n > 0 {
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					log.Printf("WebSocket pipe closed for %s: %v", target.addr, err)
+				}
+				return
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); pipe(backendConn, clientConn) }()
+	go func() { defer wg.Done(); pipe(clientConn, backendConn) }()
+	wg.Wait()
+}